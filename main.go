@@ -1,25 +1,22 @@
 package main
 
 import (
-	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"html/template"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/FerretCode-Freelancing/techrocks/site"
 	"github.com/fsnotify/fsnotify"
 	"github.com/gorilla/websocket"
-	"github.com/yuin/goldmark"
 )
 
-type PageData struct {
-	Content template.HTML
-}
-
 var logger *slog.Logger
 
 var upgrader = websocket.Upgrader{
@@ -66,6 +63,137 @@ func (h *Hub) run() {
 	}
 }
 
+// ReloadMessage is the LiveReload protocol message broadcast over the
+// websocket whenever a watched file changes. Browsers speaking the
+// LiveReload protocol use Path and the liveCSS/liveImg flags to decide
+// whether to hot-swap a stylesheet or image in place instead of doing a
+// full page reload. Our injected client script only uses Path and
+// liveCSS: it hot-swaps stylesheets and fully reloads for anything else,
+// images included.
+type ReloadMessage struct {
+	Command      string `json:"command"`
+	Path         string `json:"path"`
+	LiveCSS      bool   `json:"liveCSS"`
+	OriginalPath string `json:"originalPath"`
+	LiveImg      bool   `json:"liveImg"`
+}
+
+// newReloadMessage builds the broadcast payload for a file that changed
+// at path, marking it as CSS-swappable when its extension is ".css".
+func newReloadMessage(path string) ([]byte, error) {
+	return json.Marshal(ReloadMessage{
+		Command: "reload",
+		Path:    path,
+		LiveCSS: strings.EqualFold(filepath.Ext(path), ".css"),
+		LiveImg: true,
+	})
+}
+
+// Notify tells every connected client that path changed, using the
+// LiveReload JSON protocol so CSS-only edits can be hot-swapped.
+func (h *Hub) Notify(path string) error {
+	message, err := newReloadMessage(path)
+	if err != nil {
+		return err
+	}
+
+	h.broadcast <- message
+	return nil
+}
+
+// broadcaster is implemented by the live-reload transports (Hub for
+// websockets, SSEBroker for server-sent events) so the watcher can notify
+// clients without caring which transport is in use.
+type broadcaster interface {
+	Notify(path string) error
+}
+
+// SSEBroker is the server-sent-events analogue of Hub: it keeps a set of
+// subscriber channels and fans broadcast messages out to each of them as
+// "data: ..." frames.
+type SSEBroker struct {
+	clients    map[chan []byte]bool
+	broadcast  chan []byte
+	register   chan chan []byte
+	unregister chan chan []byte
+}
+
+func newSSEBroker() *SSEBroker {
+	return &SSEBroker{
+		clients:    make(map[chan []byte]bool),
+		broadcast:  make(chan []byte),
+		register:   make(chan chan []byte),
+		unregister: make(chan chan []byte),
+	}
+}
+
+func (b *SSEBroker) run() {
+	for {
+		select {
+		case client := <-b.register:
+			b.clients[client] = true
+		case client := <-b.unregister:
+			if _, ok := b.clients[client]; ok {
+				delete(b.clients, client)
+				close(client)
+			}
+		case message := <-b.broadcast:
+			for client := range b.clients {
+				select {
+				case client <- message:
+				default:
+					// client isn't reading (e.g. its request context was
+					// cancelled but the unregister hasn't been processed
+					// yet) - drop it instead of blocking every other
+					// subscriber and all future register/unregister traffic.
+					delete(b.clients, client)
+					close(client)
+				}
+			}
+		}
+	}
+}
+
+// Notify tells every connected SSE subscriber to reload. The SSE
+// transport doesn't carry the LiveReload JSON protocol, just the bare
+// "reload" command that Jorge-style tools use.
+func (b *SSEBroker) Notify(path string) error {
+	b.broadcast <- []byte("reload")
+	return nil
+}
+
+func serveSSE(broker *SSEBroker, w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	client := make(chan []byte, 1)
+	broker.register <- client
+	defer func() {
+		broker.unregister <- client
+	}()
+
+	for {
+		select {
+		case message, ok := <-client:
+			if !ok {
+				return
+			}
+
+			fmt.Fprintf(w, "data: %s\n\n", message)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
 func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -93,20 +221,31 @@ func main() {
 	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{})
 	logger = slog.New(handler)
 
-	// single-generation flags
-	markdownDocument := flag.String("markdown", "post.md", "the input markdown post file")
-	templateFile := flag.String("template", "template.html", "the template file for the markdown document")
-	outputFile := flag.String("output", "output.html", "the output html file")
+	// site generation flags
+	srcDir := flag.String("src", "content", "the source directory of markdown files to render")
+	templateFile := flag.String("template", "template.html", "the template file for the markdown documents")
+	outDir := flag.String("output", "dist", "the output directory for rendered html")
+	highlight := flag.String("highlight", "github", "the chroma style used to highlight fenced code blocks")
+	extensions := flag.String("extensions", "gfm", "comma-separated goldmark extensions to enable: gfm,toc,footnotes,autolink")
+	feed := flag.Bool("feed", false, "generate atom.xml from page front-matter")
+	sitemap := flag.Bool("sitemap", false, "generate sitemap.xml from page front-matter")
+	baseURL := flag.String("baseurl", "", "the base url used for feed tag uris and sitemap <loc> entries")
 
 	// live reload flags
 	watch := flag.Bool("watch", false, "watch for changes and reload the template")
 	serve := flag.Bool("serve", false, "enable server with live reload")
 	port := flag.String("port", "8080", "port for the server")
+	transport := flag.String("transport", "ws", "live reload transport to use: ws or sse")
 
 	flag.Parse()
 
-	if markdownDocument == nil || *markdownDocument == "" {
-		logger.Error("the markdown document flag must be present")
+	if *transport != "ws" && *transport != "sse" {
+		logger.Error("the transport flag must be either \"ws\" or \"sse\"", "transport", *transport)
+		return
+	}
+
+	if srcDir == nil || *srcDir == "" {
+		logger.Error("the src flag must be present")
 		return
 	}
 
@@ -115,27 +254,36 @@ func main() {
 		return
 	}
 
-	if outputFile == nil || *outputFile == "" {
-		logger.Error("the output file must be present")
+	if outDir == nil || *outDir == "" {
+		logger.Error("the output directory must be present")
 		return
 	}
 
+	opts := site.RenderOptions{
+		Highlight:  *highlight,
+		Extensions: strings.Split(*extensions, ","),
+	}
+
+	s := site.New(*srcDir, *templateFile, *outDir, opts, logger)
+	s.BaseURL = *baseURL
+	s.Feed = *feed
+	s.Sitemap = *sitemap
+
 	if *serve {
-		runServer(markdownDocument, templateFile, outputFile, port)
+		runServer(s, port, *transport)
 	} else {
-		runCli(markdownDocument, templateFile, outputFile, watch)
+		runCli(s, watch)
 	}
 }
 
-func runCli(markdownDocument, templateFile, outputFile *string, watch *bool) {
+func runCli(s *site.Site, watch *bool) {
 	if !*watch {
-		err := buildDocument(markdownDocument, templateFile, outputFile)
-		if err != nil {
-			logger.Error("there was an error building the markdown document")
+		if err := s.Build(); err != nil {
+			logger.Error("there was an error building the site")
 			return
 		}
 
-		logger.Info("the template was successfully rendered", "input", *markdownDocument, "template", *templateFile, "output", *outputFile)
+		logger.Info("the site was successfully rendered", "src", s.SrcDir, "template", s.TemplateFile, "output", s.OutDir)
 
 		return
 	}
@@ -147,20 +295,16 @@ func runCli(markdownDocument, templateFile, outputFile *string, watch *bool) {
 	}
 	defer watcher.Close()
 
-	go startWatcher(watcher, markdownDocument, templateFile, outputFile, nil)
+	go startWatcher(watcher, s, nil)
 
-	if err := buildDocument(markdownDocument, templateFile, outputFile); err != nil {
+	if err := s.Build(); err != nil {
 		logger.Error("error performing initial build", "err", err)
 	} else {
 		logger.Info("initial build successful")
 	}
 
-	if err := watcher.Add(*markdownDocument); err != nil {
-		logger.Error("error adding markdown file to watcher", "err", err)
-		return
-	}
-	if err := watcher.Add(*templateFile); err != nil {
-		logger.Error("error adding template file to watcher", "err", err)
+	if err := s.Watch(watcher); err != nil {
+		logger.Error("error adding site directory to watcher", "err", err)
 		return
 	}
 
@@ -168,9 +312,25 @@ func runCli(markdownDocument, templateFile, outputFile *string, watch *bool) {
 	<-make(chan struct{})
 }
 
-func runServer(markdownDocument, templateFile, outputFile, port *string) {
-	hub := newHub()
-	go hub.run()
+func runServer(s *site.Site, port *string, transport string) {
+	var notifier broadcaster
+
+	switch transport {
+	case "sse":
+		broker := newSSEBroker()
+		go broker.run()
+		http.HandleFunc("/_events/", func(w http.ResponseWriter, r *http.Request) {
+			serveSSE(broker, w, r)
+		})
+		notifier = broker
+	default:
+		hub := newHub()
+		go hub.run()
+		http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+			serveWs(hub, w, r)
+		})
+		notifier = hub
+	}
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -179,50 +339,40 @@ func runServer(markdownDocument, templateFile, outputFile, port *string) {
 	}
 	defer watcher.Close()
 
-	go startWatcher(watcher, markdownDocument, templateFile, outputFile, hub)
+	go startWatcher(watcher, s, notifier)
 
-	if err := buildDocument(markdownDocument, templateFile, outputFile); err != nil {
+	if err := s.Build(); err != nil {
 		logger.Error("error performing initial build", "err", err)
 	} else {
 		logger.Info("initial build successful")
 	}
 
-	if err := watcher.Add(*markdownDocument); err != nil {
-		logger.Error("error adding markdown file to watcher", "err", err)
+	if err := s.Watch(watcher); err != nil {
+		logger.Error("error adding site directory to watcher", "err", err)
 		return
 	}
 
-	if err := watcher.Add(*templateFile); err != nil {
-		logger.Error("error adding template file to watcher", "err", err)
-		return
-	}
-
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		serveWs(hub, w, r)
-	})
-
-	fs := http.FileServer(http.Dir("."))
+	fileServer := http.FileServer(http.Dir(s.OutDir))
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
-			html, err := os.ReadFile(*outputFile)
+		reqPath := r.URL.Path
+		if strings.HasSuffix(reqPath, "/") {
+			reqPath += "index.html"
+		}
+
+		if strings.HasSuffix(reqPath, ".html") {
+			html, err := os.ReadFile(filepath.Join(s.OutDir, reqPath))
 			if err != nil {
-				http.Error(w, "Could not read output file: "+err.Error(), http.StatusInternalServerError)
+				fileServer.ServeHTTP(w, r)
 				return
 			}
 
-			script := fmt.Sprintf(`<script>
-	let socket = new WebSocket("ws://%s/ws");
-	socket.onmessage = function(event) {
-		if (event.data === "reload") {
-			location.reload();
-		}
-	};
-	socket.onclose = function(event) {
-		console.log("Live reload socket closed. Reloading page to try reconnecting...");
-		setTimeout(() => location.reload(), 2000);
-	};
-</script>`, r.Host)
+			var script string
+			if transport == "sse" {
+				script = sseClientScript()
+			} else {
+				script = wsClientScript(r.Host)
+			}
 
 			injectedHTML := strings.Replace(string(html), "</body>", script+"</body>", 1)
 			w.Header().Set("Content-Type", "text/html")
@@ -231,7 +381,7 @@ func runServer(markdownDocument, templateFile, outputFile, port *string) {
 			return
 		}
 
-		fs.ServeHTTP(w, r)
+		fileServer.ServeHTTP(w, r)
 	})
 
 	logger.Info("starting server, watching for changes...", "address", "http://localhost:"+*port)
@@ -241,7 +391,84 @@ func runServer(markdownDocument, templateFile, outputFile, port *string) {
 	}
 }
 
-func startWatcher(watcher *fsnotify.Watcher, markdownDocument, templateFile, outputFile *string, hub *Hub) {
+// wsClientScript is injected into served pages when the websocket
+// transport is selected. It speaks the LiveReload JSON protocol so
+// CSS-only edits can be hot-swapped instead of triggering a full reload.
+func wsClientScript(host string) string {
+	return fmt.Sprintf(`<script>
+	let socket = new WebSocket("ws://%s/ws");
+	socket.onmessage = function(event) {
+		const msg = JSON.parse(event.data);
+		if (msg.liveCSS && msg.path.endsWith(".css")) {
+			document.querySelectorAll("link[rel='stylesheet']").forEach(function(link) {
+				const href = link.href.split("?")[0];
+				if (href.endsWith(msg.path) || href.endsWith(msg.path.split("/").pop())) {
+					link.href = href + "?reload=" + Date.now();
+				}
+			});
+			return;
+		}
+		location.reload();
+	};
+	socket.onclose = function(event) {
+		console.log("Live reload socket closed. Reloading page to try reconnecting...");
+		setTimeout(() => location.reload(), 2000);
+	};
+</script>`, host)
+}
+
+// sseClientScript is injected into served pages when the SSE transport is
+// selected. SSE avoids the websocket upgrade handshake and works through
+// more restrictive proxies, at the cost of the CSS hot-swap behavior.
+func sseClientScript() string {
+	return `<script>
+	let events = new EventSource("/_events/");
+	events.onmessage = function(event) {
+		if (event.data === "reload") {
+			location.reload();
+		}
+	};
+	events.onerror = function(event) {
+		console.log("Live reload connection lost. Reloading page to try reconnecting...");
+		setTimeout(() => location.reload(), 2000);
+	};
+</script>`
+}
+
+// rebuildDebounce coalesces watcher events on the same path into a single
+// rebuild, since editors commonly write a file then rename it into place
+// within a few milliseconds of each other.
+const rebuildDebounce = 150 * time.Millisecond
+
+// debouncer delays calling a per-key function until no new call for that
+// key has arrived within the configured window.
+type debouncer struct {
+	mu     sync.Mutex
+	delay  time.Duration
+	timers map[string]*time.Timer
+}
+
+func newDebouncer(delay time.Duration) *debouncer {
+	return &debouncer{
+		delay:  delay,
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+func (d *debouncer) trigger(key string, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if t, ok := d.timers[key]; ok {
+		t.Stop()
+	}
+
+	d.timers[key] = time.AfterFunc(d.delay, fn)
+}
+
+func startWatcher(watcher *fsnotify.Watcher, s *site.Site, hub broadcaster) {
+	deb := newDebouncer(rebuildDebounce)
+
 	for {
 		select {
 		case event, ok := <-watcher.Events:
@@ -249,31 +476,23 @@ func startWatcher(watcher *fsnotify.Watcher, markdownDocument, templateFile, out
 				return
 			}
 
-			if !(event.Has(fsnotify.Write) || event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove)) {
-				continue
-			}
-
-			if event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove) {
-				time.Sleep(100 * time.Millisecond)
-			}
-
-			logger.Info("change detected, rebuilding...", "file", event.Name, "op", event.Op.String())
-
-			if err := buildDocument(markdownDocument, templateFile, outputFile); err != nil {
-				if !(event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove)) {
-					logger.Error("error rebuilding document", "err", err)
-				}
-			} else {
-				logger.Info("rebuild successful")
-				if hub != nil {
-					hub.broadcast <- []byte("reload")
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watcher.Add(event.Name); err != nil {
+						logger.Error("error watching new directory", "dir", event.Name, "err", err)
+					}
+					continue
 				}
 			}
 
-			if event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove) {
-				watcher.Add(*markdownDocument)
-				watcher.Add(*templateFile)
+			if !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove)) {
+				continue
 			}
+
+			path, op := event.Name, event.Op
+			deb.trigger(path, func() {
+				rebuild(watcher, s, hub, path, op)
+			})
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return
@@ -284,40 +503,52 @@ func startWatcher(watcher *fsnotify.Watcher, markdownDocument, templateFile, out
 	}
 }
 
-func buildDocument(markdownDocument *string, templateFile *string, outputFile *string) error {
-	markdownContent, err := os.ReadFile(*markdownDocument)
-	if err != nil {
-		logger.Error("there was an error parsing the markdown content", "err", err)
-		return err
-	}
+// rebuild runs the coalesced rebuild for a single watcher path: the
+// changed markdown file itself, or every page that depends on it if it's
+// the shared template.
+func rebuild(watcher *fsnotify.Watcher, s *site.Site, hub broadcaster, path string, op fsnotify.Op) {
+	renamedOrRemoved := op.Has(fsnotify.Rename) || op.Has(fsnotify.Remove)
 
-	var buf bytes.Buffer
-	if err := goldmark.Convert(markdownContent, &buf); err != nil {
-		logger.Error("error converting markdown into html", "err", err)
-		return err
+	if renamedOrRemoved {
+		time.Sleep(100 * time.Millisecond)
 	}
 
-	tmpl, err := template.ParseFiles(*templateFile)
-	if err != nil {
-		logger.Error("error parsing template file", "err", err)
-		return err
+	logger.Info("change detected, rebuilding...", "file", path, "op", op.String())
+
+	targets := []string{path}
+	if path == s.TemplateFile {
+		targets = s.PagesForTemplate(path)
 	}
 
-	output, err := os.Create(*outputFile)
-	if err != nil {
-		logger.Error("error creating output file", "err", err)
-		return err
+	var rebuildErr error
+	for _, target := range targets {
+		if err := s.BuildFile(target); err != nil {
+			rebuildErr = err
+		}
 	}
-	defer output.Close()
 
-	data := PageData{
-		Content: template.HTML(buf.String()),
+	if rebuildErr == nil {
+		if err := s.WriteFeeds(); err != nil {
+			rebuildErr = err
+		}
 	}
 
-	if err := tmpl.Execute(output, data); err != nil {
-		logger.Error("error rendering template with markdown", "err", err)
-		return err
+	if rebuildErr != nil {
+		if !renamedOrRemoved {
+			logger.Error("error rebuilding document", "err", rebuildErr)
+		}
+	} else {
+		logger.Info("rebuild successful")
+		if hub != nil {
+			if err := hub.Notify(path); err != nil {
+				logger.Error("error encoding reload message", "err", err)
+			}
+		}
 	}
 
-	return nil
+	if renamedOrRemoved {
+		if err := s.Watch(watcher); err != nil {
+			logger.Error("error re-adding site directory to watcher", "err", err)
+		}
+	}
 }
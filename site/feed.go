@@ -0,0 +1,137 @@
+package site
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PageMeta is the front-matter and path info collected for a page during
+// a build, used to generate the atom feed and sitemap.
+type PageMeta struct {
+	Title     string
+	Date      time.Time
+	Permalink string
+	Summary   string
+	ModTime   time.Time
+}
+
+// recordPage adds or updates meta in s.Pages, keyed by Permalink, so
+// repeated builds of the same page (e.g. from the watcher) don't grow the
+// feed and sitemap with duplicate entries.
+func (s *Site) recordPage(meta PageMeta) {
+	for i, p := range s.Pages {
+		if p.Permalink == meta.Permalink {
+			s.Pages[i] = meta
+			return
+		}
+	}
+
+	s.Pages = append(s.Pages, meta)
+}
+
+// permalink turns a path relative to SrcDir into the site-relative URL
+// the rendered page is served at.
+func permalink(relPath string) string {
+	htmlName := strings.TrimSuffix(relPath, filepath.Ext(relPath)) + ".html"
+	return "/" + filepath.ToSlash(htmlName)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary,omitempty"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+// GenerateFeed writes atom.xml to OutDir, built from the front-matter of
+// every page collected so far.
+func (s *Site) GenerateFeed() error {
+	host := strings.TrimSuffix(s.BaseURL, "/")
+
+	feed := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Title:   "Site Feed",
+		ID:      host + "/",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	for _, p := range s.Pages {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   p.Title,
+			ID:      tagURI(host, p),
+			Updated: p.Date.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: host + p.Permalink},
+			Summary: p.Summary,
+		})
+	}
+
+	return writeXMLFile(filepath.Join(s.OutDir, "atom.xml"), feed)
+}
+
+// tagURI builds a tag: URI (host + yyyy-mm-dd + slug) for an atom entry's
+// id, as recommended by RFC 4151 for feed entries that don't have a
+// stable URL of their own.
+func tagURI(host string, p PageMeta) string {
+	hostname := host
+	hostname = strings.TrimPrefix(hostname, "https://")
+	hostname = strings.TrimPrefix(hostname, "http://")
+
+	slug := strings.Trim(p.Permalink, "/")
+	return fmt.Sprintf("tag:%s,%s:%s", hostname, p.Date.Format("2006-01-02"), slug)
+}
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// GenerateSitemap writes sitemap.xml to OutDir, with a <lastmod> for each
+// page taken from its source file's mtime.
+func (s *Site) GenerateSitemap() error {
+	host := strings.TrimSuffix(s.BaseURL, "/")
+
+	set := urlSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+
+	for _, p := range s.Pages {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     host + p.Permalink,
+			LastMod: p.ModTime.UTC().Format("2006-01-02"),
+		})
+	}
+
+	return writeXMLFile(filepath.Join(s.OutDir, "sitemap.xml"), set)
+}
+
+func writeXMLFile(path string, v any) error {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	content := append([]byte(xml.Header), out...)
+	return os.WriteFile(path, content, 0o644)
+}
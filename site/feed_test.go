@@ -0,0 +1,119 @@
+package site
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordPageDedupesByPermalink(t *testing.T) {
+	s := &Site{}
+
+	first := PageMeta{Title: "First", Permalink: "/post/a.html"}
+	s.recordPage(first)
+
+	updated := PageMeta{Title: "First, updated", Permalink: "/post/a.html"}
+	s.recordPage(updated)
+
+	s.recordPage(PageMeta{Title: "Second", Permalink: "/post/b.html"})
+
+	if len(s.Pages) != 2 {
+		t.Fatalf("len(Pages) = %d, want 2", len(s.Pages))
+	}
+	if s.Pages[0].Title != "First, updated" {
+		t.Errorf("Pages[0].Title = %q, want the updated title", s.Pages[0].Title)
+	}
+}
+
+func TestPermalink(t *testing.T) {
+	cases := []struct {
+		relPath string
+		want    string
+	}{
+		{"post.md", "/post.html"},
+		{filepath.Join("blog", "post.md"), "/blog/post.html"},
+	}
+
+	for _, c := range cases {
+		if got := permalink(c.relPath); got != c.want {
+			t.Errorf("permalink(%q) = %q, want %q", c.relPath, got, c.want)
+		}
+	}
+}
+
+func TestTagURI(t *testing.T) {
+	p := PageMeta{
+		Permalink: "/blog/hello-world.html",
+		Date:      time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+	}
+
+	got := tagURI("https://example.com", p)
+	want := "tag:example.com,2024-03-05:blog/hello-world.html"
+	if got != want {
+		t.Errorf("tagURI() = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateFeedEmptyBaseURL(t *testing.T) {
+	dir := t.TempDir()
+	s := &Site{OutDir: dir}
+	s.recordPage(PageMeta{
+		Title:     "Hello",
+		Permalink: "/hello.html",
+		Date:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+
+	if err := s.GenerateFeed(); err != nil {
+		t.Fatalf("GenerateFeed() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "atom.xml"))
+	if err != nil {
+		t.Fatalf("reading atom.xml: %v", err)
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		t.Fatalf("unmarshaling atom.xml: %v", err)
+	}
+	if len(feed.Entries) != 1 {
+		t.Fatalf("len(Entries) = %d, want 1", len(feed.Entries))
+	}
+	if feed.Entries[0].Link.Href != "/hello.html" {
+		t.Errorf("Link.Href = %q, want %q (baseurl empty)", feed.Entries[0].Link.Href, "/hello.html")
+	}
+}
+
+func TestGenerateSitemap(t *testing.T) {
+	dir := t.TempDir()
+	s := &Site{OutDir: dir, BaseURL: "https://example.com"}
+	s.recordPage(PageMeta{
+		Permalink: "/hello.html",
+		ModTime:   time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	})
+
+	if err := s.GenerateSitemap(); err != nil {
+		t.Fatalf("GenerateSitemap() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("reading sitemap.xml: %v", err)
+	}
+
+	var set urlSet
+	if err := xml.Unmarshal(data, &set); err != nil {
+		t.Fatalf("unmarshaling sitemap.xml: %v", err)
+	}
+	if len(set.URLs) != 1 {
+		t.Fatalf("len(URLs) = %d, want 1", len(set.URLs))
+	}
+	if set.URLs[0].Loc != "https://example.com/hello.html" {
+		t.Errorf("Loc = %q, want %q", set.URLs[0].Loc, "https://example.com/hello.html")
+	}
+	if set.URLs[0].LastMod != "2024-01-02" {
+		t.Errorf("LastMod = %q, want %q", set.URLs[0].LastMod, "2024-01-02")
+	}
+}
@@ -0,0 +1,69 @@
+package site
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatterDelim marks the start and end of a Jekyll/Hugo-style
+// front-matter block at the top of a markdown file.
+var frontMatterDelim = []byte("---")
+
+// errUnterminatedFrontMatter is returned when a file opens a front-matter
+// block with "---" but never closes it.
+var errUnterminatedFrontMatter = errors.New("unterminated front-matter block")
+
+// FrontMatter is the parsed metadata block at the top of a markdown
+// file, plus whatever else the author put in it.
+type FrontMatter struct {
+	Title     string         `yaml:"title"`
+	Date      time.Time      `yaml:"date"`
+	Tags      []string       `yaml:"tags"`
+	Permalink string         `yaml:"permalink"`
+	Summary   string         `yaml:"summary"`
+	Meta      map[string]any `yaml:",inline"`
+}
+
+// splitFrontMatter separates a leading "---"-delimited YAML block from
+// the rest of the markdown content. If content does not open with a
+// delimiter line, it is returned unchanged with a zero-value FrontMatter.
+func splitFrontMatter(content []byte) (FrontMatter, []byte, error) {
+	var fm FrontMatter
+
+	lines := bytes.Split(bytes.TrimLeft(content, "\r\n"), []byte("\n"))
+	if len(lines) == 0 || !isDelimiterLine(lines[0]) {
+		return fm, content, nil
+	}
+
+	closeIdx := -1
+	for i := 1; i < len(lines); i++ {
+		if isDelimiterLine(lines[i]) {
+			closeIdx = i
+			break
+		}
+	}
+
+	if closeIdx == -1 {
+		return fm, nil, errUnterminatedFrontMatter
+	}
+
+	block := bytes.Join(lines[1:closeIdx], []byte("\n"))
+	body := bytes.Join(lines[closeIdx+1:], []byte("\n"))
+
+	if err := yaml.Unmarshal(block, &fm); err != nil {
+		return fm, nil, err
+	}
+
+	return fm, bytes.TrimLeft(body, "\r\n"), nil
+}
+
+// isDelimiterLine reports whether line is exactly "---", allowing
+// trailing whitespace, the way Jekyll/Hugo detect a front-matter
+// delimiter. A bare prefix match would misread a CommonMark thematic
+// break ("----") at the top of a file as the start of front-matter.
+func isDelimiterLine(line []byte) bool {
+	return bytes.Equal(bytes.TrimRight(line, " \t\r"), frontMatterDelim)
+}
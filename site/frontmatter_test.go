@@ -0,0 +1,110 @@
+package site
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatterNoBlock(t *testing.T) {
+	content := []byte("# Just a heading\n\nsome text\n")
+
+	fm, body, err := splitFrontMatter(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fm.Title != "" {
+		t.Errorf("expected zero-value FrontMatter, got Title %q", fm.Title)
+	}
+	if string(body) != string(content) {
+		t.Errorf("body = %q, want content unchanged", body)
+	}
+}
+
+func TestSplitFrontMatterThematicBreak(t *testing.T) {
+	// A CommonMark thematic break at the very top of a file must not be
+	// misread as the start of a front-matter block.
+	content := []byte("----\n\n# Heading\n")
+
+	fm, body, err := splitFrontMatter(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fm.Title != "" {
+		t.Errorf("expected zero-value FrontMatter, got Title %q", fm.Title)
+	}
+	if string(body) != string(content) {
+		t.Errorf("body = %q, want content unchanged", body)
+	}
+}
+
+func TestSplitFrontMatterBasic(t *testing.T) {
+	content := []byte("---\ntitle: Hello World\ntags: [a, b]\n---\n# Hello\n")
+
+	fm, body, err := splitFrontMatter(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fm.Title != "Hello World" {
+		t.Errorf("Title = %q, want %q", fm.Title, "Hello World")
+	}
+	if len(fm.Tags) != 2 || fm.Tags[0] != "a" || fm.Tags[1] != "b" {
+		t.Errorf("Tags = %v, want [a b]", fm.Tags)
+	}
+	if string(body) != "# Hello\n" {
+		t.Errorf("body = %q, want %q", body, "# Hello\n")
+	}
+}
+
+func TestSplitFrontMatterUnterminated(t *testing.T) {
+	content := []byte("---\ntitle: Hello World\n# Hello\n")
+
+	_, _, err := splitFrontMatter(content)
+	if err != errUnterminatedFrontMatter {
+		t.Fatalf("err = %v, want errUnterminatedFrontMatter", err)
+	}
+}
+
+func TestSplitFrontMatterMalformedYAML(t *testing.T) {
+	content := []byte("---\ntitle: [unclosed\n---\nbody\n")
+
+	_, _, err := splitFrontMatter(content)
+	if err == nil {
+		t.Fatal("expected an error for malformed YAML, got nil")
+	}
+}
+
+func TestIsDelimiterLine(t *testing.T) {
+	cases := []struct {
+		line string
+		want bool
+	}{
+		{"---", true},
+		{"---  ", true},
+		{"---\r", true},
+		{"----", false},
+		{"-- -", false},
+		{" ---", false},
+		{"", false},
+	}
+
+	for _, c := range cases {
+		if got := isDelimiterLine([]byte(c.line)); got != c.want {
+			t.Errorf("isDelimiterLine(%q) = %v, want %v", c.line, got, c.want)
+		}
+	}
+}
+
+func TestSplitFrontMatterTrimsLeadingNewlines(t *testing.T) {
+	content := []byte("\n\n---\ntitle: X\n---\nbody\n")
+
+	fm, body, err := splitFrontMatter(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fm.Title != "X" {
+		t.Errorf("Title = %q, want %q", fm.Title, "X")
+	}
+	if strings.TrimSpace(string(body)) != "body" {
+		t.Errorf("body = %q, want %q", body, "body")
+	}
+}
@@ -0,0 +1,66 @@
+package site
+
+import (
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// RenderOptions configures the shared goldmark pipeline used for every
+// page in the site: which Chroma style fenced code blocks are highlighted
+// with, and which optional extensions are enabled.
+type RenderOptions struct {
+	Highlight  string
+	Extensions []string
+}
+
+// DefaultRenderOptions enables only GitHub-flavored markdown, which is the
+// closest thing to what this tool rendered before the extension pipeline
+// existed. Unlike a bare goldmark.Convert call, the pipeline newMarkdown
+// builds from it also highlights fenced code blocks with the "github"
+// style and assigns heading ids.
+func DefaultRenderOptions() RenderOptions {
+	return RenderOptions{
+		Highlight:  "github",
+		Extensions: []string{"gfm"},
+	}
+}
+
+func (o RenderOptions) has(name string) bool {
+	for _, e := range o.Extensions {
+		if e == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newMarkdown builds a goldmark.Markdown configured once from opts,
+// instead of the bare goldmark.Convert call every page used to make on
+// its own.
+func newMarkdown(opts RenderOptions) goldmark.Markdown {
+	var exts []goldmark.Extender
+
+	if opts.has("gfm") {
+		exts = append(exts, extension.GFM)
+	}
+	if opts.has("footnotes") {
+		exts = append(exts, extension.Footnote)
+	}
+	if opts.has("autolink") {
+		exts = append(exts, extension.NewLinkify())
+	}
+
+	style := opts.Highlight
+	if style == "" {
+		style = "github"
+	}
+	exts = append(exts, highlighting.NewHighlighting(highlighting.WithStyle(style)))
+
+	return goldmark.New(
+		goldmark.WithExtensions(exts...),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+}
@@ -0,0 +1,272 @@
+// Package site implements the markdown-to-html build pipeline: walking a
+// source directory, rendering each page through goldmark and a shared
+// html/template, and writing the result to a mirrored path under the
+// output directory.
+package site
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/text"
+)
+
+// PageData is the data made available to page templates.
+type PageData struct {
+	Content template.HTML
+	Title   string
+	Date    time.Time
+	Tags    []string
+	Meta    map[string]any
+	TOC     []TOCEntry
+}
+
+// Site holds the resolved configuration for a single build: where the
+// markdown source lives, which template renders it, and where the
+// rendered html is written.
+type Site struct {
+	SrcDir       string
+	TemplateFile string
+	OutDir       string
+	Logger       *slog.Logger
+	Options      RenderOptions
+
+	// BaseURL, Feed, and Sitemap control the optional atom.xml/sitemap.xml
+	// generation that runs after a build. Pages accumulates the
+	// front-matter collected for each page rendered so far.
+	BaseURL string
+	Feed    bool
+	Sitemap bool
+	Pages   []PageMeta
+
+	// deps maps a template path to the markdown source files it was used
+	// to render, so a template change only rebuilds its dependents
+	// instead of the whole site.
+	deps map[string][]string
+
+	markdown goldmark.Markdown
+
+	// mu serializes BuildFile so concurrent rebuilds (e.g. from debounced
+	// watcher events firing on separate goroutines) can't race on deps,
+	// Pages, or the feed files.
+	mu sync.Mutex
+}
+
+// New returns a Site configured for the given source directory, template
+// file, and output directory. The goldmark parser/renderer pipeline
+// described by opts is built once here and reused for every page.
+func New(srcDir, templateFile, outDir string, opts RenderOptions, logger *slog.Logger) *Site {
+	return &Site{
+		SrcDir:       srcDir,
+		TemplateFile: templateFile,
+		OutDir:       outDir,
+		Logger:       logger,
+		Options:      opts,
+		markdown:     newMarkdown(opts),
+		deps:         make(map[string][]string),
+	}
+}
+
+// PagesForTemplate returns the markdown source files previously rendered
+// with templatePath, so a template-only change can rebuild just its
+// dependents. It's safe to call concurrently with BuildFile.
+func (s *Site) PagesForTemplate(templatePath string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deps := s.deps[templatePath]
+	pages := make([]string, len(deps))
+	copy(pages, deps)
+	return pages
+}
+
+// recordDep notes that srcPath was last rendered with templatePath.
+func (s *Site) recordDep(templatePath, srcPath string) {
+	for _, existing := range s.deps[templatePath] {
+		if existing == srcPath {
+			return
+		}
+	}
+
+	s.deps[templatePath] = append(s.deps[templatePath], srcPath)
+}
+
+// Build walks SrcDir and renders every markdown file found to a mirrored
+// path under OutDir, then writes the atom feed and/or sitemap once for
+// the whole site.
+func (s *Site) Build() error {
+	err := filepath.WalkDir(s.SrcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+
+		if err := s.BuildFile(path); err != nil {
+			// BuildFile already logged the specific failure; don't let one
+			// bad page (e.g. malformed front-matter) abort the rest of the
+			// walk.
+			return nil
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.WriteFeeds()
+}
+
+// BuildFile renders the single markdown file at srcPath to its mirrored
+// location under OutDir. It's safe to call concurrently.
+func (s *Site) BuildFile(srcPath string) error {
+	if !strings.EqualFold(filepath.Ext(srcPath), ".md") {
+		// Not a page - e.g. a CSS asset or other file living under SrcDir
+		// that the recursive watcher also reports changes for. Nothing to
+		// render.
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	relPath, err := filepath.Rel(s.SrcDir, srcPath)
+	if err != nil {
+		s.Logger.Error("error resolving path relative to source directory", "path", srcPath, "err", err)
+		return err
+	}
+
+	markdownContent, err := os.ReadFile(srcPath)
+	if err != nil {
+		s.Logger.Error("there was an error parsing the markdown content", "err", err)
+		return err
+	}
+
+	fm, body, err := splitFrontMatter(markdownContent)
+	if err != nil {
+		s.Logger.Error("malformed front-matter, skipping render", "path", srcPath, "err", err)
+		return err
+	}
+
+	doc := s.markdown.Parser().Parse(text.NewReader(body))
+
+	var toc []TOCEntry
+	if s.Options.has("toc") {
+		toc = buildTOC(doc, body)
+	}
+
+	var buf bytes.Buffer
+	if err := s.markdown.Renderer().Render(&buf, body, doc); err != nil {
+		s.Logger.Error("error converting markdown into html", "err", err)
+		return err
+	}
+
+	tmpl, err := template.ParseFiles(s.TemplateFile)
+	if err != nil {
+		s.Logger.Error("error parsing template file", "err", err)
+		return err
+	}
+
+	s.recordDep(s.TemplateFile, srcPath)
+
+	outPath := s.outputPath(relPath)
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		s.Logger.Error("error creating output directory", "err", err)
+		return err
+	}
+
+	output, err := os.Create(outPath)
+	if err != nil {
+		s.Logger.Error("error creating output file", "err", err)
+		return err
+	}
+	defer output.Close()
+
+	data := PageData{
+		Content: template.HTML(buf.String()),
+		Title:   fm.Title,
+		Date:    fm.Date,
+		Tags:    fm.Tags,
+		Meta:    fm.Meta,
+		TOC:     toc,
+	}
+
+	if err := tmpl.Execute(output, data); err != nil {
+		s.Logger.Error("error rendering template with markdown", "err", err)
+		return err
+	}
+
+	link := fm.Permalink
+	if link == "" {
+		link = permalink(relPath)
+	}
+
+	var modTime time.Time
+	if info, err := os.Stat(srcPath); err == nil {
+		modTime = info.ModTime()
+	}
+
+	s.recordPage(PageMeta{
+		Title:     fm.Title,
+		Date:      fm.Date,
+		Permalink: link,
+		Summary:   fm.Summary,
+		ModTime:   modTime,
+	})
+
+	return nil
+}
+
+// WriteFeeds regenerates atom.xml and/or sitemap.xml, if enabled, from
+// the pages collected so far. Build calls this once after a full walk;
+// callers doing their own incremental, single-file rebuilds (e.g. the
+// watcher) should call it after each one.
+func (s *Site) WriteFeeds() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Feed {
+		if err := s.GenerateFeed(); err != nil {
+			s.Logger.Error("error generating atom feed", "err", err)
+			return err
+		}
+	}
+
+	if s.Sitemap {
+		if err := s.GenerateSitemap(); err != nil {
+			s.Logger.Error("error generating sitemap", "err", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// outputPath maps a markdown path relative to SrcDir to its rendered
+// location under OutDir, swapping the extension for ".html".
+func (s *Site) outputPath(relPath string) string {
+	htmlName := strings.TrimSuffix(relPath, filepath.Ext(relPath)) + ".html"
+	return filepath.Join(s.OutDir, htmlName)
+}
+
+// Contains reports whether path lies within SrcDir, i.e. whether a
+// changed file belongs to this site.
+func (s *Site) Contains(path string) bool {
+	rel, err := filepath.Rel(s.SrcDir, path)
+	if err != nil {
+		return false
+	}
+
+	return !strings.HasPrefix(rel, "..")
+}
@@ -0,0 +1,92 @@
+package site
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// TOCEntry is one heading in a page's table of contents, with its nested
+// subheadings attached as Children.
+type TOCEntry struct {
+	Title    string
+	Anchor   string
+	Level    int
+	Children []TOCEntry
+}
+
+// buildTOC walks doc for headings and returns them as a tree nested by
+// heading level, using the anchor ids parser.WithAutoHeadingID() assigns.
+func buildTOC(doc ast.Node, source []byte) []TOCEntry {
+	var roots []TOCEntry
+	var stack []*TOCEntry
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		heading, ok := n.(*ast.Heading)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		entry := TOCEntry{
+			Title:  headingText(heading, source),
+			Level:  heading.Level,
+			Anchor: headingAnchor(heading),
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].Level >= entry.Level {
+			stack = stack[:len(stack)-1]
+		}
+
+		if len(stack) == 0 {
+			roots = append(roots, entry)
+			stack = append(stack, &roots[len(roots)-1])
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+			stack = append(stack, &parent.Children[len(parent.Children)-1])
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return roots
+}
+
+// headingText concatenates the plain text of a heading, ignoring any
+// inline formatting nodes.
+func headingText(h *ast.Heading, source []byte) string {
+	var buf bytes.Buffer
+
+	ast.Walk(h, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		if t, ok := n.(*ast.Text); ok {
+			buf.Write(t.Segment.Value(source))
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return buf.String()
+}
+
+// headingAnchor returns the id attribute the parser assigned to h.
+func headingAnchor(h *ast.Heading) string {
+	value, ok := h.AttributeString("id")
+	if !ok {
+		return ""
+	}
+
+	id, ok := value.([]byte)
+	if !ok {
+		return ""
+	}
+
+	return string(id)
+}
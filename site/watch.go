@@ -0,0 +1,29 @@
+package site
+
+import (
+	"io/fs"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch adds every directory under SrcDir, recursively, plus
+// TemplateFile, to watcher so that edits anywhere in the tree trigger a
+// rebuild.
+func (s *Site) Watch(watcher *fsnotify.Watcher) error {
+	if err := filepath.WalkDir(s.SrcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return watcher.Add(s.TemplateFile)
+}